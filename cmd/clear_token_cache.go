@@ -0,0 +1,38 @@
+// Copyright 2016 Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/GoogleCloudPlatform/docker-credential-gcr/store"
+)
+
+// ClearTokenCacheCmd implements "docker-credential-gcr clear-token-cache",
+// which deletes every token the in-process cache has persisted to disk.
+func ClearTokenCacheCmd(args []string) error {
+	storeDir, err := store.DefaultDir()
+	if err != nil {
+		return fmt.Errorf("could not locate store directory: %v", err)
+	}
+
+	cacheDir := filepath.Join(storeDir, "token-cache")
+	if err := os.RemoveAll(cacheDir); err != nil {
+		return fmt.Errorf("could not clear token cache at %s: %v", cacheDir, err)
+	}
+	return nil
+}
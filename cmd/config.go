@@ -0,0 +1,95 @@
+// Copyright 2016 Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package cmd implements the docker-credential-gcr command-line subcommands.
+*/
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/docker-credential-gcr/config"
+)
+
+// ConfigCmd implements "docker-credential-gcr config", which edits the
+// user's persisted settings in place.
+func ConfigCmd(args []string) error {
+	fs := flag.NewFlagSet("config", flag.ContinueOnError)
+	gcloudAccount := fs.String("gcloud-account", "", "pin the gcloud_sdk token source to this account instead of gcloud's active one")
+	impersonateServiceAccount := fs.String("impersonate-service-account", "", "mint GCR tokens by impersonating this service account email")
+	impersonateDelegates := fs.String("impersonate-delegates", "", "comma-separated chain of service accounts to delegate through to reach --impersonate-service-account")
+	envTokenVar := fs.String("env-token-var", "", "environment variable the env_token token source reads a bearer token from (default GCR_ACCESS_TOKEN)")
+	tokenFile := fs.String("token-file", "", "path the token_file token source re-reads a bearer token from on every call")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	path, err := config.DefaultPath()
+	if err != nil {
+		return fmt.Errorf("could not locate config file: %v", err)
+	}
+
+	cfg, err := config.Load(path)
+	if err != nil {
+		return fmt.Errorf("could not read config file %s: %v", path, err)
+	}
+
+	if isFlagSet(fs, "gcloud-account") {
+		cfg.GcloudAccount = *gcloudAccount
+	}
+	if isFlagSet(fs, "impersonate-service-account") {
+		cfg.ImpersonateServiceAccount = *impersonateServiceAccount
+	}
+	if isFlagSet(fs, "impersonate-delegates") {
+		cfg.ImpersonateDelegates = splitNonEmpty(*impersonateDelegates, ",")
+	}
+	if isFlagSet(fs, "env-token-var") {
+		cfg.EnvTokenVar = *envTokenVar
+	}
+	if isFlagSet(fs, "token-file") {
+		cfg.TokenFile = *tokenFile
+	}
+
+	if err := config.Save(path, cfg); err != nil {
+		return fmt.Errorf("could not write config file %s: %v", path, err)
+	}
+	return nil
+}
+
+// splitNonEmpty splits s on sep, dropping empty elements, so that
+// "" and trailing separators don't produce spurious entries.
+func splitNonEmpty(s, sep string) []string {
+	var out []string
+	for _, part := range strings.Split(s, sep) {
+		if part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
+
+// isFlagSet reports whether name was explicitly passed on the command line,
+// as opposed to left at its default value.
+func isFlagSet(fs *flag.FlagSet, name string) bool {
+	set := false
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
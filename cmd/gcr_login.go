@@ -0,0 +1,67 @@
+// Copyright 2016 Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/GoogleCloudPlatform/docker-credential-gcr/config"
+	"github.com/GoogleCloudPlatform/docker-credential-gcr/store"
+	"golang.org/x/oauth2/google"
+)
+
+// GCRLoginCmd implements "docker-credential-gcr gcr-login", which installs
+// credentials the helper's "store" token source can later serve.
+func GCRLoginCmd(s store.GCRCredStore, args []string) error {
+	fs := flag.NewFlagSet("gcr-login", flag.ContinueOnError)
+	workloadIdentityFile := fs.String("workload-identity-file", "", "register a Workload Identity Federation external_account credential file, validating it by minting one token")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *workloadIdentityFile == "" {
+		return helperErr("gcr-login requires --workload-identity-file")
+	}
+	return registerExternalAccount(s, *workloadIdentityFile)
+}
+
+// registerExternalAccount validates the external_account credential file at
+// path by using it to mint one access token, then persists it verbatim to
+// s so that the "store" token source can serve it on future Get() calls.
+func registerExternalAccount(s store.GCRCredStore, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("could not read %s: %v", path, err)
+	}
+
+	creds, err := google.CredentialsFromJSON(config.OAuthHTTPContext, data, config.GCRScopes...)
+	if err != nil {
+		return fmt.Errorf("%s does not look like a valid external_account credential: %v", path, err)
+	}
+	if _, err := creds.TokenSource.Token(); err != nil {
+		return fmt.Errorf("could not mint a token from %s: %v", path, err)
+	}
+
+	if err := s.SetGCRAuth(&store.Auth{ExternalAccountJSON: data}); err != nil {
+		return fmt.Errorf("could not persist external_account credentials: %v", err)
+	}
+	return nil
+}
+
+func helperErr(msg string) error {
+	return fmt.Errorf("docker-credential-gcr: %s", msg)
+}
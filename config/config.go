@@ -0,0 +1,168 @@
+// Copyright 2016 Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+)
+
+// OAuthHTTPContext is the context.Context used for all outbound OAuth2
+// token requests made by this package.
+var OAuthHTTPContext = context.Background()
+
+// defaultTokenSources is the order in which token sources are consulted
+// when the user has not configured any explicitly.
+var defaultTokenSources = []string{"store", "gcloud_sdk", "env"}
+
+// UserConfig holds the persisted, user-configurable settings for the
+// credential helper.
+type UserConfig struct {
+	TokenSourceOrder []string `json:"token_source_order,omitempty"`
+
+	// GcloudAccount, when set, pins the gcloud SDK token source to a
+	// specific account rather than whichever one gcloud considers active.
+	GcloudAccount string `json:"gcloud_account,omitempty"`
+
+	// ImpersonateServiceAccount, when set, is the email of the service
+	// account the "impersonate" token source mints access tokens for.
+	ImpersonateServiceAccount string `json:"impersonate_service_account,omitempty"`
+	// ImpersonateDelegates is an optional chain of service accounts to
+	// delegate through en route to ImpersonateServiceAccount, each one
+	// needing to have granted the next Service Account Token Creator.
+	ImpersonateDelegates []string `json:"impersonate_delegates,omitempty"`
+	// ImpersonateLifetimeSeconds bounds how long a minted impersonated
+	// token is valid for. Zero means the IAM Credentials API default.
+	ImpersonateLifetimeSeconds int `json:"impersonate_lifetime_seconds,omitempty"`
+
+	// TokenCache controls whether minted GCR access tokens are cached to
+	// disk between invocations of the helper. Defaults to on; set to
+	// false to force a fresh token on every call.
+	TokenCache *bool `json:"token_cache,omitempty"`
+
+	// EnvTokenVar names the environment variable the "env_token" token
+	// source reads a bearer token from. Defaults to GCR_ACCESS_TOKEN.
+	EnvTokenVar string `json:"env_token_var,omitempty"`
+	// TokenFile is the path the "token_file" token source re-reads a
+	// bearer token from on every call, letting an external process
+	// rotate it in place.
+	TokenFile string `json:"token_file,omitempty"`
+}
+
+// DefaultEnvTokenVar is the environment variable the "env_token" token
+// source reads from when EnvTokenVar isn't set.
+const DefaultEnvTokenVar = "GCR_ACCESS_TOKEN"
+
+// EnvTokenVarName returns the configured environment variable name for the
+// "env_token" token source, falling back to DefaultEnvTokenVar.
+func (c UserConfig) EnvTokenVarName() string {
+	if c.EnvTokenVar == "" {
+		return DefaultEnvTokenVar
+	}
+	return c.EnvTokenVar
+}
+
+// TokenCacheEnabled reports whether the on-disk token cache should be used,
+// which is the case unless the user has explicitly disabled it.
+func (c UserConfig) TokenCacheEnabled() bool {
+	return c.TokenCache == nil || *c.TokenCache
+}
+
+// TokenSources returns the configured token source order. If the user has
+// set TokenSourceOrder explicitly, that order is used verbatim. Otherwise
+// the order is built from defaultTokenSources, with sources implied by
+// other settings spliced in ahead of it so that, e.g., setting
+// ImpersonateServiceAccount or GcloudAccount alone is enough to activate
+// the corresponding source without also hand-editing the order:
+//
+//   - ImpersonateServiceAccount != "" tries "impersonate" first, since it's
+//     the whole point of setting it (typically a CI job with only a
+//     workload identity that needs to act as a different, more-privileged
+//     service account).
+//   - TokenFile != "" tries "token_file" next: it's an explicit override
+//     naming a specific file to read a token from.
+//   - The env_token_var-named environment variable being set tries
+//     "env_token" next, for the same reason - its entire purpose is to work
+//     in a minimal container image with nothing else configured.
+//   - GcloudAccount != "" tries the account-pinned "gcloud_sdk_account"
+//     ahead of the generic "gcloud_sdk", since it's what actually honors
+//     the pinned account; "gcloud_sdk" stays in the chain right after it
+//     as a fallback, since "gcloud_sdk_account" only understands gcloud's
+//     legacy credentials.json export and errors out on current gcloud
+//     installs' SQLite credentials.db.
+func (c UserConfig) TokenSources() []string {
+	if len(c.TokenSourceOrder) != 0 {
+		return c.TokenSourceOrder
+	}
+
+	sources := make([]string, 0, len(defaultTokenSources)+4)
+	if c.ImpersonateServiceAccount != "" {
+		sources = append(sources, "impersonate")
+	}
+	if c.TokenFile != "" {
+		sources = append(sources, "token_file")
+	}
+	if os.Getenv(c.EnvTokenVarName()) != "" {
+		sources = append(sources, "env_token")
+	}
+	for _, source := range defaultTokenSources {
+		if source == "gcloud_sdk" && c.GcloudAccount != "" {
+			sources = append(sources, "gcloud_sdk_account")
+		}
+		sources = append(sources, source)
+	}
+	return sources
+}
+
+// DefaultPath returns the path to the user's persisted config file,
+// "~/.docker-credential-gcr/config.json".
+func DefaultPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".docker-credential-gcr", "config.json"), nil
+}
+
+// Load reads the UserConfig from path. A missing file is not an error; it
+// yields the zero-value UserConfig.
+func Load(path string) (UserConfig, error) {
+	var cfg UserConfig
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return cfg, nil
+	}
+	if err != nil {
+		return cfg, err
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return cfg, err
+	}
+	return cfg, nil
+}
+
+// Save writes cfg to path, creating parent directories as needed.
+func Save(path string, cfg UserConfig) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
@@ -0,0 +1,90 @@
+// Copyright 2016 Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTokenSources(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  UserConfig
+		want []string
+	}{
+		{
+			name: "default",
+			cfg:  UserConfig{},
+			want: []string{"store", "gcloud_sdk", "env"},
+		},
+		{
+			name: "explicit order wins",
+			cfg:  UserConfig{TokenSourceOrder: []string{"env"}, ImpersonateServiceAccount: "sa@example.com"},
+			want: []string{"env"},
+		},
+		{
+			name: "gcloud account tries gcloud_sdk_account ahead of gcloud_sdk",
+			cfg:  UserConfig{GcloudAccount: "me@example.com"},
+			want: []string{"store", "gcloud_sdk_account", "gcloud_sdk", "env"},
+		},
+		{
+			name: "impersonate service account activates impersonate source",
+			cfg:  UserConfig{ImpersonateServiceAccount: "sa@example.com"},
+			want: []string{"impersonate", "store", "gcloud_sdk", "env"},
+		},
+		{
+			name: "impersonate and gcloud account both apply",
+			cfg:  UserConfig{ImpersonateServiceAccount: "sa@example.com", GcloudAccount: "me@example.com"},
+			want: []string{"impersonate", "store", "gcloud_sdk_account", "gcloud_sdk", "env"},
+		},
+		{
+			name: "token file activates token_file source",
+			cfg:  UserConfig{TokenFile: "/var/run/secrets/token"},
+			want: []string{"token_file", "store", "gcloud_sdk", "env"},
+		},
+		{
+			name: "env token var unset does not activate env_token",
+			cfg:  UserConfig{EnvTokenVar: "MY_TOKEN"},
+			want: []string{"store", "gcloud_sdk", "env"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.cfg.TokenSources(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("TokenSources() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTokenSourcesEnvToken(t *testing.T) {
+	t.Setenv(DefaultEnvTokenVar, "a-token")
+	cfg := UserConfig{}
+	want := []string{"env_token", "store", "gcloud_sdk", "env"}
+	if got := cfg.TokenSources(); !reflect.DeepEqual(got, want) {
+		t.Errorf("TokenSources() = %v, want %v", got, want)
+	}
+}
+
+func TestTokenSourcesEnvTokenCustomVar(t *testing.T) {
+	t.Setenv("MY_TOKEN", "a-token")
+	cfg := UserConfig{EnvTokenVar: "MY_TOKEN"}
+	want := []string{"env_token", "store", "gcloud_sdk", "env"}
+	if got := cfg.TokenSources(); !reflect.DeepEqual(got, want) {
+		t.Errorf("TokenSources() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,68 @@
+// Copyright 2016 Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import "strings"
+
+// SupportedGCRRegistries enumerates the legacy GCR hostnames (gcr.io, its
+// subdomains, and gcr.kubernetes.io) that the credential helper understands
+// out of the box.
+var SupportedGCRRegistries = map[string]bool{
+	"gcr.io":                  true,
+	"us.gcr.io":               true,
+	"eu.gcr.io":               true,
+	"asia.gcr.io":             true,
+	"staging-k8s.gcr.io":      true,
+	"asia.staging-k8s.gcr.io": true,
+	"eu.staging-k8s.gcr.io":   true,
+	"us.staging-k8s.gcr.io":   true,
+	"b.gcr.io":                true,
+	"bucket.gcr.io":           true,
+	"appengine.gcr.io":        true,
+	"gcr.kubernetes.io":       true,
+	"beta.gcr.io":             true,
+}
+
+// artifactRegistryHostSuffix matches Artifact Registry's regional Docker
+// endpoints, e.g. "us-docker.pkg.dev" or "europe-west1-docker.pkg.dev".
+const artifactRegistryHostSuffix = "-docker.pkg.dev"
+
+// IsArtifactRegistryHost returns true if host is a Docker-format Artifact
+// Registry hostname, e.g. "us-docker.pkg.dev".
+func IsArtifactRegistryHost(host string) bool {
+	return strings.HasSuffix(host, artifactRegistryHostSuffix)
+}
+
+// GCRScopes are the OAuth2 scopes requested when minting a token for one of
+// the legacy hosts in SupportedGCRRegistries.
+var GCRScopes = []string{
+	"https://www.googleapis.com/auth/devstorage.read_write",
+}
+
+// ArtifactRegistryScopes are the OAuth2 scopes requested when minting a
+// token for an Artifact Registry host.
+var ArtifactRegistryScopes = []string{
+	"https://www.googleapis.com/auth/cloud-platform",
+}
+
+// SupportedArtifactRegistryRegistries lists the multi-region Artifact
+// Registry hosts that can be enumerated ahead of time. Single-region hosts
+// (e.g. "us-east1-docker.pkg.dev") are matched by IsArtifactRegistryHost
+// instead, since there are too many to list exhaustively.
+var SupportedArtifactRegistryRegistries = map[string]bool{
+	"us-docker.pkg.dev":     true,
+	"europe-docker.pkg.dev": true,
+	"asia-docker.pkg.dev":   true,
+}
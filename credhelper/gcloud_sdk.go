@@ -0,0 +1,177 @@
+// Copyright 2016 Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package credhelper
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/GoogleCloudPlatform/docker-credential-gcr/config"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+)
+
+// gcloudSDKConfigDir returns the directory gcloud stores its configuration
+// in, honoring CLOUDSDK_CONFIG the same way the gcloud CLI itself does.
+func gcloudSDKConfigDir() (string, error) {
+	if dir := os.Getenv("CLOUDSDK_CONFIG"); dir != "" {
+		return dir, nil
+	}
+	if runtime.GOOS == "windows" {
+		if appData := os.Getenv("APPDATA"); appData != "" {
+			return filepath.Join(appData, "gcloud"), nil
+		}
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".config", "gcloud"), nil
+}
+
+// gcloudSDKActiveAccount returns the "core/account" property from gcloud's
+// "properties" file, i.e. the account `gcloud auth login` last activated.
+func gcloudSDKActiveAccount(configDir string) (string, error) {
+	f, err := os.Open(filepath.Join(configDir, "properties"))
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	inCoreSection := false
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "" || strings.HasPrefix(line, "#"):
+			continue
+		case strings.HasPrefix(line, "["):
+			inCoreSection = line == "[core]"
+		case inCoreSection:
+			key, value, ok := strings.Cut(line, "=")
+			if ok && strings.TrimSpace(key) == "account" {
+				return strings.TrimSpace(value), nil
+			}
+		}
+	}
+	return "", scanner.Err()
+}
+
+// gcloudCredentialsFile mirrors the layout of gcloud's legacy
+// "credentials.json" export: one entry per authorized account, keyed by
+// account email, with an embedded OAuth2 client and refresh/access token.
+type gcloudCredentialsFile struct {
+	Data []gcloudCredentialsEntry `json:"data"`
+}
+
+type gcloudCredentialsEntry struct {
+	Key struct {
+		Account string `json:"account"`
+	} `json:"key"`
+	Credential struct {
+		ClientID     string    `json:"client_id"`
+		ClientSecret string    `json:"client_secret"`
+		RefreshToken string    `json:"refresh_token"`
+		AccessToken  string    `json:"access_token"`
+		TokenExpiry  time.Time `json:"token_expiry"`
+	} `json:"credential"`
+}
+
+// sqliteFileMagic is the header every SQLite database file starts with.
+// Current gcloud versions store "credentials.db" as a SQLite database
+// (distinct from the JSON schema in gcloudCredentialsFile), which this
+// package does not parse; see gcloudSDKAccountCreds.
+var sqliteFileMagic = []byte("SQLite format 3\x00")
+
+// gcloudSDKAccountCreds loads the OAuth2 client configuration and token for
+// account from gcloud's on-disk credential store.
+//
+// Current gcloud versions keep "credentials.db" as a SQLite database, which
+// this package deliberately does not parse to avoid pulling in a SQLite
+// driver. Only the legacy "credentials.json" export (`gcloud auth
+// application-default print-access-token` predecessors produced this format)
+// is supported; if "credentials.db" is present and looks like SQLite, this
+// returns a clear error pointing at gcloud_sdk instead.
+func gcloudSDKAccountCreds(configDir, account string) (*oauth2.Config, *oauth2.Token, error) {
+	if data, err := os.ReadFile(filepath.Join(configDir, "credentials.db")); err == nil {
+		if bytes.HasPrefix(data, sqliteFileMagic) {
+			return nil, nil, helperErr("gcloud_sdk_account does not support SQLite credentials.db; use the gcloud_sdk token source instead, or export a legacy credentials.json", nil)
+		}
+	}
+
+	data, err := os.ReadFile(filepath.Join(configDir, "credentials.json"))
+	if err != nil {
+		return nil, nil, helperErr("could not read gcloud SDK credentials.json", err)
+	}
+
+	var creds gcloudCredentialsFile
+	if err := json.Unmarshal(data, &creds); err != nil {
+		return nil, nil, helperErr("could not parse gcloud SDK credentials.json", err)
+	}
+
+	for _, entry := range creds.Data {
+		if entry.Key.Account != account {
+			continue
+		}
+		cfg := &oauth2.Config{
+			ClientID:     entry.Credential.ClientID,
+			ClientSecret: entry.Credential.ClientSecret,
+			Endpoint:     google.Endpoint,
+		}
+		tok := &oauth2.Token{
+			RefreshToken: entry.Credential.RefreshToken,
+			AccessToken:  entry.Credential.AccessToken,
+			Expiry:       entry.Credential.TokenExpiry,
+		}
+		return cfg, tok, nil
+	}
+	return nil, nil, helperErr("no gcloud SDK credentials found for account "+account, nil)
+}
+
+// tokenFromGcloudSDKAccount mints a GCR access token directly from gcloud's
+// on-disk SDK configuration, bypassing the `gcloud` binary entirely. If
+// account is empty, the active account from gcloud's "properties" file is
+// used.
+func tokenFromGcloudSDKAccount(account string, scopes []string) (string, error) {
+	configDir, err := gcloudSDKConfigDir()
+	if err != nil {
+		return "", err
+	}
+
+	if account == "" {
+		account, err = gcloudSDKActiveAccount(configDir)
+		if err != nil {
+			return "", helperErr("could not determine active gcloud SDK account", err)
+		}
+	}
+
+	cfg, tok, err := gcloudSDKAccountCreds(configDir, account)
+	if err != nil {
+		return "", err
+	}
+	cfg.Scopes = scopes
+
+	newTok, err := cfg.TokenSource(config.OAuthHTTPContext, tok).Token()
+	if err != nil {
+		return "", err
+	}
+	return newTok.AccessToken, nil
+}
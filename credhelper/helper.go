@@ -24,11 +24,13 @@ import (
 	"net/url"
 	"os/exec"
 	"strings"
+	"time"
 
 	"github.com/GoogleCloudPlatform/docker-credential-gcr/config"
 	"github.com/GoogleCloudPlatform/docker-credential-gcr/store"
 	"github.com/docker/docker-credential-helpers/credentials"
 
+	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
 )
 
@@ -37,13 +39,27 @@ const gcrOAuth2Username = "oauth2accesstoken"
 // gcrCredHelper implements a credentials.Helper interface backed by a GCR
 // credential store.
 type gcrCredHelper struct {
-	store        store.GCRCredStore
-	tokenSources []string
+	store         store.GCRCredStore
+	tokenSources  []string
+	gcloudAccount string
+
+	impersonateTarget    string
+	impersonateDelegates []string
+	impersonateLifetime  time.Duration
+
+	tokenCacheEnabled bool
+
+	envTokenVar string
+	tokenFile   string
 
 	// helper methods, package exposed for testing
-	envToken       func() (string, error)
-	gcloudSDKToken func() (string, error)
-	credStoreToken func(store.GCRCredStore) (string, error)
+	envToken              func(scopes []string) (string, error)
+	gcloudSDKToken        func(scopes []string) (string, error)
+	gcloudSDKAccountToken func(account string, scopes []string) (string, error)
+	credStoreToken        func(store store.GCRCredStore, scopes []string) (string, error)
+	impersonateToken      func(target string, delegates []string, lifetime time.Duration, scopes []string) (string, time.Time, error)
+	envVarToken           func(varName string) (string, error)
+	tokenFileToken        func(path string) (string, error)
 }
 
 // NewGCRCredentialHelper returns a Docker credential helper which
@@ -51,11 +67,22 @@ type gcrCredHelper struct {
 func NewGCRCredentialHelper(store store.GCRCredStore, userCfg config.UserConfig) credentials.Helper {
 	tokenSources := userCfg.TokenSources()
 	return &gcrCredHelper{
-		store:          store,
-		tokenSources:   tokenSources,
-		credStoreToken: tokenFromPrivateStore,
-		gcloudSDKToken: tokenFromGcloudSDK,
-		envToken:       tokenFromEnv,
+		store:                 store,
+		tokenSources:          tokenSources,
+		gcloudAccount:         userCfg.GcloudAccount,
+		impersonateTarget:     userCfg.ImpersonateServiceAccount,
+		impersonateDelegates:  userCfg.ImpersonateDelegates,
+		impersonateLifetime:   time.Duration(userCfg.ImpersonateLifetimeSeconds) * time.Second,
+		tokenCacheEnabled:     userCfg.TokenCacheEnabled(),
+		envTokenVar:           userCfg.EnvTokenVarName(),
+		tokenFile:             userCfg.TokenFile,
+		credStoreToken:        tokenFromPrivateStore,
+		gcloudSDKToken:        tokenFromGcloudSDK,
+		gcloudSDKAccountToken: tokenFromGcloudSDKAccount,
+		envToken:              tokenFromEnv,
+		impersonateToken:      tokenFromImpersonation,
+		envVarToken:           tokenFromEnvVar,
+		tokenFileToken:        tokenFromTokenFile,
 	}
 }
 
@@ -76,6 +103,10 @@ func (ch *gcrCredHelper) List() (map[string]string, error) {
 		resp[gcrRegistry] = gcrOAuth2Username
 	}
 
+	for arRegistry := range config.SupportedArtifactRegistryRegistries {
+		resp[arRegistry] = gcrOAuth2Username
+	}
+
 	return resp, nil
 }
 
@@ -108,7 +139,7 @@ func (ch *gcrCredHelper) Delete(serverURL string) error {
 func (ch *gcrCredHelper) Get(serverURL string) (string, string, error) {
 	if isAGCRHostname(serverURL) {
 		// Return GCR's access token.
-		accessToken, err := ch.getGCRAccessToken()
+		accessToken, err := ch.getGCRAccessToken(scopesForHostname(serverURL))
 		if err != nil {
 			return "", "", helperErr(fmt.Sprintf("could not retrieve %s's access token", serverURL), err)
 		}
@@ -126,53 +157,124 @@ func (ch *gcrCredHelper) Get(serverURL string) (string, string, error) {
 	return creds.Username, creds.Secret, nil
 }
 
-// getGCRAccessToken attempts to retrieve a GCR access token from the sources
-// listed by ch.tokenSources, in order.
-func (ch *gcrCredHelper) getGCRAccessToken() (string, error) {
+// getGCRAccessToken attempts to retrieve a GCR access token scoped to
+// scopes from the sources listed by ch.tokenSources, in order. Successful
+// results are cached on disk (see token_cache.go) and reused until they're
+// about to expire, so that repeated invocations of the helper - as happens
+// once per image/layer in some docker pull flows - don't each mint a new
+// token.
+func (ch *gcrCredHelper) getGCRAccessToken(scopes []string) (string, error) {
+	var cacheKey string
+	if ch.tokenCacheEnabled {
+		cacheKey = tokenCacheKey(ch.tokenSources, ch.gcloudAccount, ch.impersonateTarget, ch.impersonateDelegates, scopes)
+		if cached, err := loadCachedToken(cacheKey); err == nil && cached.valid() {
+			return cached.AccessToken, nil
+		}
+	}
+
 	var token string
 	var err error
+	var expiry time.Time
+	var usedSource string
 	for _, source := range ch.tokenSources {
 		switch source {
 		case "env":
-			token, err = ch.envToken()
+			token, err = ch.envToken(scopes)
 		case "gcloud_sdk":
-			token, err = ch.gcloudSDKToken()
+			token, err = ch.gcloudSDKToken(scopes)
+		case "gcloud_sdk_account":
+			token, err = ch.gcloudSDKAccountToken(ch.gcloudAccount, scopes)
+		case "impersonate":
+			token, expiry, err = ch.impersonateToken(ch.impersonateTarget, ch.impersonateDelegates, ch.impersonateLifetime, scopes)
 		case "store":
-			token, err = ch.credStoreToken(ch.store)
+			token, err = ch.credStoreToken(ch.store, scopes)
+		case "env_token":
+			token, err = ch.envVarToken(ch.envTokenVar)
+		case "token_file":
+			token, err = ch.tokenFileToken(ch.tokenFile)
 		default:
 			return "", helperErr("unknown token source: "+source, nil)
 		}
 
 		// if we successfully retrieved a token, break.
 		if err == nil {
+			usedSource = source
 			break
 		}
 	}
 
+	if err == nil && ch.tokenCacheEnabled && tokenSourceIsCacheable(usedSource) {
+		// Most token sources only hand back the raw access_token string,
+		// not its real expiry, so fall back to assuming the standard
+		// Google OAuth2 access token lifetime. "impersonate" is the
+		// exception: it returns its actual expiry above. Caching is
+		// best-effort: a write failure shouldn't fail the Get() call.
+		tokenExpiry := expiry
+		if tokenExpiry.IsZero() {
+			tokenExpiry = time.Now().Add(assumedTokenLifetime)
+		}
+		cached := &cachedToken{
+			AccessToken: token,
+			TokenType:   "Bearer",
+			Expiry:      tokenExpiry,
+		}
+		_ = saveCachedToken(cacheKey, cached)
+	}
+
 	return token, err
 }
 
+// tokenSourceIsCacheable reports whether a token minted by source is safe
+// to cache on disk. "env_token" and "token_file" are deliberately excluded:
+// both exist to let an external process hand the helper a token it doesn't
+// control the lifetime of - env_token via a process environment set up
+// per-invocation, token_file via a file an external refresher rotates in
+// place - so caching either would serve a stale or revoked token until the
+// assumed lifetime lapsed.
+func tokenSourceIsCacheable(source string) bool {
+	switch source {
+	case "env_token", "token_file":
+		return false
+	default:
+		return true
+	}
+}
+
+// scopesForHostname returns the OAuth2 scopes that should be requested for
+// a token that will authenticate against serverURL.
+func scopesForHostname(serverURL string) []string {
+	URL, err := url.Parse(serverURL)
+	host := serverURL
+	if err == nil && URL.Host != "" {
+		host = URL.Host
+	}
+	if config.IsArtifactRegistryHost(host) || config.SupportedArtifactRegistryRegistries[host] {
+		return config.ArtifactRegistryScopes
+	}
+	return config.GCRScopes
+}
+
 /*
-	tokenFromEnv retrieves a gcloud access_token from the environment.
+tokenFromEnv retrieves a gcloud access_token from the environment.
 
-	From https://godoc.org/golang.org/x/oauth2/google:
+From https://godoc.org/golang.org/x/oauth2/google:
 
-	DefaultTokenSource is a token source that uses "Application Default Credentials".
+DefaultTokenSource is a token source that uses "Application Default Credentials".
 
-	It looks for credentials in the following places, preferring the first location found:
+It looks for credentials in the following places, preferring the first location found:
 
-	1. A JSON file whose path is specified by the
-	   GOOGLE_APPLICATION_CREDENTIALS environment variable.
-	2. A JSON file in a location known to the gcloud command-line tool.
-	   On Windows, this is %APPDATA%/gcloud/application_default_credentials.json.
-	   On other systems, $HOME/.config/gcloud/application_default_credentials.json.
-	3. On Google App Engine it uses the appengine.AccessToken function.
-	4. On Google Compute Engine and Google App Engine Managed VMs, it fetches
-	   credentials from the metadata server.
-	   (In this final case any provided scopes are ignored.)
+ 1. A JSON file whose path is specified by the
+    GOOGLE_APPLICATION_CREDENTIALS environment variable.
+ 2. A JSON file in a location known to the gcloud command-line tool.
+    On Windows, this is %APPDATA%/gcloud/application_default_credentials.json.
+    On other systems, $HOME/.config/gcloud/application_default_credentials.json.
+ 3. On Google App Engine it uses the appengine.AccessToken function.
+ 4. On Google Compute Engine and Google App Engine Managed VMs, it fetches
+    credentials from the metadata server.
+    (In this final case any provided scopes are ignored.)
 */
-func tokenFromEnv() (string, error) {
-	ts, err := google.DefaultTokenSource(config.OAuthHTTPContext, config.GCRScopes...)
+func tokenFromEnv(scopes []string) (string, error) {
+	ts, err := google.DefaultTokenSource(config.OAuthHTTPContext, scopes...)
 	if err != nil {
 		return "", err
 	}
@@ -194,7 +296,10 @@ func tokenFromEnv() (string, error) {
 }
 
 // tokenFromGcloudSDK attempts to generate an access_token using the gcloud SDK.
-func tokenFromGcloudSDK() (string, error) {
+// gcloud always mints a token for the active account's configured scopes, so
+// scopes is accepted for interface parity with the other token sources but
+// otherwise unused.
+func tokenFromGcloudSDK(scopes []string) (string, error) {
 	// shelling out to gcloud is the only currently supported way of
 	// obtaining the gcloud access_token
 	if _, err := exec.LookPath("gcloud"); err != nil {
@@ -216,12 +321,28 @@ func tokenFromGcloudSDK() (string, error) {
 	return token, nil
 }
 
-func tokenFromPrivateStore(store store.GCRCredStore) (string, error) {
-	gcrAuth, err := store.GetGCRAuth()
+// tokenFromPrivateStore refreshes the GCR token stashed in the credential
+// store. A user refresh token is refreshed via its own oauth2.Config; a
+// Workload Identity Federation external_account credential is instead
+// exchanged via google.CredentialsFromJSON using scopes, since it carries
+// no refresh token of its own.
+func tokenFromPrivateStore(gcrStore store.GCRCredStore, scopes []string) (string, error) {
+	gcrAuth, err := gcrStore.GetGCRAuth()
 	if err != nil {
 		return "", err
 	}
-	ts := gcrAuth.TokenSource(config.OAuthHTTPContext)
+
+	var ts oauth2.TokenSource
+	if gcrAuth.IsExternalAccount() {
+		creds, err := google.CredentialsFromJSON(config.OAuthHTTPContext, gcrAuth.ExternalAccountJSON, scopes...)
+		if err != nil {
+			return "", helperErr("could not load external_account credentials", err)
+		}
+		ts = creds.TokenSource
+	} else {
+		ts = gcrAuth.TokenSource(config.OAuthHTTPContext)
+	}
+
 	tok, err := ts.Token()
 	if err != nil {
 		return "", err
@@ -233,13 +354,19 @@ func tokenFromPrivateStore(store store.GCRCredStore) (string, error) {
 	return tok.AccessToken, nil
 }
 
-// isAGCRHostname returns true if the given hostname is one of GCR's
+// isAGCRHostname returns true if the given hostname is one of GCR's, or one
+// of Artifact Registry's Docker-format hosts (e.g. "us-docker.pkg.dev",
+// "europe-west1-docker.pkg.dev").
 func isAGCRHostname(serverURL string) bool {
 	URL, err := url.Parse(serverURL)
-	if err != nil {
-		return false
+	host := serverURL
+	if err == nil && URL.Host != "" {
+		host = URL.Host
+	}
+	if config.SupportedGCRRegistries[host] || config.SupportedGCRRegistries[serverURL] {
+		return true
 	}
-	return config.SupportedGCRRegistries[URL.Host] || config.SupportedGCRRegistries[serverURL]
+	return config.IsArtifactRegistryHost(host) || config.IsArtifactRegistryHost(serverURL)
 }
 
 func helperErr(message string, err error) error {
@@ -0,0 +1,134 @@
+// Copyright 2016 Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package credhelper
+
+import (
+	"testing"
+
+	"github.com/GoogleCloudPlatform/docker-credential-gcr/store"
+	"github.com/docker/docker-credential-helpers/credentials"
+)
+
+// fakeStore is a minimal in-memory store.GCRCredStore for tests that don't
+// exercise GCR token retrieval.
+type fakeStore struct {
+	other map[string]*credentials.Credentials
+}
+
+func newFakeStore() *fakeStore {
+	return &fakeStore{other: make(map[string]*credentials.Credentials)}
+}
+
+func (s *fakeStore) GetGCRAuth() (*store.Auth, error)  { return nil, helperErr("not implemented", nil) }
+func (s *fakeStore) SetGCRAuth(auth *store.Auth) error { return nil }
+
+func (s *fakeStore) GetOtherCreds(serverURL string) (*credentials.Credentials, error) {
+	creds, ok := s.other[serverURL]
+	if !ok {
+		return nil, credentials.NewErrCredentialsNotFound()
+	}
+	return creds, nil
+}
+
+func (s *fakeStore) SetOtherCreds(creds *credentials.Credentials) error {
+	s.other[creds.ServerURL] = creds
+	return nil
+}
+
+func (s *fakeStore) DeleteOtherCreds(serverURL string) error {
+	delete(s.other, serverURL)
+	return nil
+}
+
+func (s *fakeStore) AllThirdPartyCreds() (map[string]*credentials.Credentials, error) {
+	return s.other, nil
+}
+
+func TestIsAGCRHostname(t *testing.T) {
+	tests := []struct {
+		host string
+		want bool
+	}{
+		// legacy GCR hosts
+		{"gcr.io", true},
+		{"us.gcr.io", true},
+		{"https://eu.gcr.io", true},
+		// Artifact Registry hosts
+		{"us-docker.pkg.dev", true},
+		{"europe-west1-docker.pkg.dev", true},
+		{"https://asia-docker.pkg.dev", true},
+		// neither
+		{"docker.io", false},
+		{"notpkg.dev", false},
+		{"example.com", false},
+	}
+
+	for _, tt := range tests {
+		if got := isAGCRHostname(tt.host); got != tt.want {
+			t.Errorf("isAGCRHostname(%q) = %v, want %v", tt.host, got, tt.want)
+		}
+	}
+}
+
+func TestScopesForHostname(t *testing.T) {
+	tests := []struct {
+		host   string
+		wantAR bool
+	}{
+		{"gcr.io", false},
+		{"us.gcr.io", false},
+		{"us-docker.pkg.dev", true},
+		{"europe-west1-docker.pkg.dev", true},
+	}
+
+	for _, tt := range tests {
+		scopes := scopesForHostname(tt.host)
+		if len(scopes) == 0 {
+			t.Fatalf("scopesForHostname(%q) returned no scopes", tt.host)
+		}
+		isCloudPlatform := scopes[0] == "https://www.googleapis.com/auth/cloud-platform"
+		if isCloudPlatform != tt.wantAR {
+			t.Errorf("scopesForHostname(%q) = %v, want AR scopes: %v", tt.host, scopes, tt.wantAR)
+		}
+	}
+}
+
+// TestThirdPartyCredsOnPkgDevLikeHost ensures a pkg.dev-style host that
+// isn't actually an Artifact Registry Docker endpoint (no "-docker." infix,
+// e.g. a private proxy under a customer's own pkg.dev-adjacent domain) is
+// still treated as a normal third-party registry.
+func TestThirdPartyCredsOnPkgDevLikeHost(t *testing.T) {
+	const proxyURL = "https://my-proxy.pkg.dev"
+
+	s := newFakeStore()
+	ch := &gcrCredHelper{store: s}
+
+	creds := &credentials.Credentials{
+		ServerURL: proxyURL,
+		Username:  "proxy-user",
+		Secret:    "proxy-secret",
+	}
+	if err := ch.Add(creds); err != nil {
+		t.Fatalf("Add() = %v, want nil", err)
+	}
+
+	user, secret, err := ch.Get(proxyURL)
+	if err != nil {
+		t.Fatalf("Get() = %v, want nil", err)
+	}
+	if user != creds.Username || secret != creds.Secret {
+		t.Errorf("Get() = (%q, %q), want (%q, %q)", user, secret, creds.Username, creds.Secret)
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright 2016 Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package credhelper
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/GoogleCloudPlatform/docker-credential-gcr/config"
+	"golang.org/x/oauth2/google"
+)
+
+const generateAccessTokenURLFmt = "https://iamcredentials.googleapis.com/v1/projects/-/serviceAccounts/%s:generateAccessToken"
+
+// generateAccessTokenRequest is the body of an IAM Credentials API
+// generateAccessToken call.
+type generateAccessTokenRequest struct {
+	Delegates []string `json:"delegates,omitempty"`
+	Scope     []string `json:"scope"`
+	Lifetime  string   `json:"lifetime,omitempty"`
+}
+
+// generateAccessTokenResponse is the response of an IAM Credentials API
+// generateAccessToken call.
+type generateAccessTokenResponse struct {
+	AccessToken string    `json:"accessToken"`
+	ExpireTime  time.Time `json:"expireTime"`
+}
+
+// tokenFromImpersonation mints a short-lived access token for
+// targetPrincipal, a service account email, by calling the IAM
+// Credentials API with the ambient Application Default Credentials as the
+// caller. delegates is an optional chain of intermediate service accounts,
+// and lifetime bounds how long the minted token will be valid for (zero
+// uses the API's default). The returned time.Time is the token's real
+// expiry (per the API response), for the caller to use when deciding how
+// long the token can be cached.
+func tokenFromImpersonation(targetPrincipal string, delegates []string, lifetime time.Duration, scopes []string) (string, time.Time, error) {
+	if targetPrincipal == "" {
+		return "", time.Time{}, helperErr("no impersonate_service_account configured", nil)
+	}
+
+	sourceTS, err := google.DefaultTokenSource(config.OAuthHTTPContext)
+	if err != nil {
+		return "", time.Time{}, helperErr("could not load source credentials for impersonation", err)
+	}
+	sourceTok, err := sourceTS.Token()
+	if err != nil {
+		return "", time.Time{}, helperErr("could not mint source token for impersonation", err)
+	}
+
+	reqBody := generateAccessTokenRequest{
+		Delegates: delegates,
+		Scope:     scopes,
+	}
+	if lifetime > 0 {
+		reqBody.Lifetime = fmt.Sprintf("%ds", int(lifetime.Seconds()))
+	}
+	body, err := json.Marshal(reqBody)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	url := fmt.Sprintf(generateAccessTokenURLFmt, targetPrincipal)
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+sourceTok.AccessToken)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", time.Time{}, helperErr("generateAccessToken request failed", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", time.Time{}, helperErr(fmt.Sprintf("generateAccessToken for %s returned status %s", targetPrincipal, resp.Status), nil)
+	}
+
+	var tokenResp generateAccessTokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&tokenResp); err != nil {
+		return "", time.Time{}, helperErr("could not parse generateAccessToken response", err)
+	}
+
+	return tokenResp.AccessToken, tokenResp.ExpireTime, nil
+}
@@ -0,0 +1,63 @@
+// Copyright 2016 Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package credhelper
+
+import (
+	"os"
+	"strings"
+
+	"golang.org/x/oauth2"
+)
+
+// validateBearerToken runs the same sanity checks tokenFromEnv applies to
+// tokens it mints, so statically-sourced tokens are held to the same bar.
+func validateBearerToken(accessToken string) (string, error) {
+	tok := &oauth2.Token{AccessToken: accessToken, TokenType: "Bearer"}
+	if !tok.Valid() {
+		return "", helperErr("token was invalid", nil)
+	}
+	if tok.Type() != "Bearer" {
+		return "", helperErr("expected token type \"Bearer\"", nil)
+	}
+	return tok.AccessToken, nil
+}
+
+// tokenFromEnvVar reads a bearer token from the named environment variable,
+// trimming surrounding whitespace, and skips google.DefaultTokenSource entirely. This lets
+// sandboxed environments without ADC wiring still authenticate.
+func tokenFromEnvVar(varName string) (string, error) {
+	token := strings.TrimSpace(os.Getenv(varName))
+	if token == "" {
+		return "", helperErr(varName+" is not set", nil)
+	}
+	return validateBearerToken(token)
+}
+
+// tokenFromTokenFile reads a bearer token from path, re-reading on every
+// call so that an external refresher (sidecar, systemd timer, a cron job
+// piping `gcloud auth print-access-token` to the file) can rotate it
+// without restarting anything.
+func tokenFromTokenFile(path string) (string, error) {
+	if path == "" {
+		return "", helperErr("no token_file configured", nil)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", helperErr("could not read token_file "+path, err)
+	}
+
+	return validateBearerToken(strings.TrimSpace(string(data)))
+}
@@ -0,0 +1,76 @@
+// Copyright 2016 Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package credhelper
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTokenFromEnvVar(t *testing.T) {
+	t.Setenv("TEST_GCR_TOKEN", "  a-token  ")
+
+	token, err := tokenFromEnvVar("TEST_GCR_TOKEN")
+	if err != nil {
+		t.Fatalf("tokenFromEnvVar() = %v, want nil", err)
+	}
+	if token != "a-token" {
+		t.Errorf("tokenFromEnvVar() = %q, want %q", token, "a-token")
+	}
+}
+
+func TestTokenFromEnvVarUnset(t *testing.T) {
+	if _, err := tokenFromEnvVar("TEST_GCR_TOKEN_UNSET"); err == nil {
+		t.Error("tokenFromEnvVar() with unset var = nil error, want error")
+	}
+}
+
+func TestTokenFromTokenFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "token")
+	writeFile(t, path, "file-token\n")
+
+	token, err := tokenFromTokenFile(path)
+	if err != nil {
+		t.Fatalf("tokenFromTokenFile() = %v, want nil", err)
+	}
+	if token != "file-token" {
+		t.Errorf("tokenFromTokenFile() = %q, want %q", token, "file-token")
+	}
+
+	// Rewrite the file and confirm the next read picks up the change,
+	// since an external refresher may rotate it at any time.
+	writeFile(t, path, "rotated-token\n")
+	token, err = tokenFromTokenFile(path)
+	if err != nil {
+		t.Fatalf("tokenFromTokenFile() after rotation = %v, want nil", err)
+	}
+	if token != "rotated-token" {
+		t.Errorf("tokenFromTokenFile() after rotation = %q, want %q", token, "rotated-token")
+	}
+}
+
+func TestTokenFromTokenFileMissing(t *testing.T) {
+	if _, err := tokenFromTokenFile(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("tokenFromTokenFile() with missing file = nil error, want error")
+	}
+}
+
+func writeFile(t *testing.T, path, contents string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(contents), 0600); err != nil {
+		t.Fatalf("could not write %s: %v", path, err)
+	}
+}
@@ -0,0 +1,159 @@
+// Copyright 2016 Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package credhelper
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/GoogleCloudPlatform/docker-credential-gcr/store"
+)
+
+// tokenCacheExpiryLeeway is subtracted from a cached token's expiry so that
+// callers never hand out a token that's about to lapse mid-request.
+const tokenCacheExpiryLeeway = 30 * time.Second
+
+// assumedTokenLifetime is how long a freshly minted token is assumed to
+// remain valid when caching it, since most of getGCRAccessToken's token
+// sources only return the bare access_token string rather than its real
+// expiry ("impersonate" returns its real expiry, which is used instead).
+// This matches the lifetime Google's OAuth2 token endpoint issues today.
+const assumedTokenLifetime = 1 * time.Hour
+
+// cachedToken is the on-disk representation of a previously minted GCR
+// access token.
+type cachedToken struct {
+	AccessToken string    `json:"access_token"`
+	TokenType   string    `json:"token_type"`
+	Expiry      time.Time `json:"expiry"`
+}
+
+// valid reports whether the cached token can still be handed out.
+func (t *cachedToken) valid() bool {
+	return t != nil && t.AccessToken != "" && time.Now().Before(t.Expiry.Add(-tokenCacheExpiryLeeway))
+}
+
+// tokenCacheDir returns the directory token cache files are written to,
+// alongside the on-disk credential store.
+func tokenCacheDir() (string, error) {
+	storeDir, err := storeDirFn()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(storeDir, "token-cache"), nil
+}
+
+// tokenCacheKey derives a filename-safe cache key from the inputs that
+// determine which access token getGCRAccessToken would mint: the ordered
+// token sources it will try, the selected gcloud account (if any), the
+// impersonation target and delegate chain (if any - order matters for
+// delegates, since it's the chain of identities delegated through, not an
+// unordered set), and the requested OAuth2 scopes.
+func tokenCacheKey(tokenSources []string, account string, impersonateTarget string, impersonateDelegates []string, scopes []string) string {
+	sortedScopes := append([]string(nil), scopes...)
+	sort.Strings(sortedScopes)
+
+	h := sha256.New()
+	fmt.Fprintf(h, "%s|%s|%s|%s|%s", strings.Join(tokenSources, ","), account, impersonateTarget, strings.Join(impersonateDelegates, ","), strings.Join(sortedScopes, ","))
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+// loadCachedToken reads the cached token for key, if any. A missing or
+// unreadable cache file is not an error: it just means there's no usable
+// cached token.
+func loadCachedToken(key string) (*cachedToken, error) {
+	dir, err := tokenCacheDir()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, key+".json"))
+	if err != nil {
+		return nil, err
+	}
+
+	var tok cachedToken
+	if err := json.Unmarshal(data, &tok); err != nil {
+		return nil, err
+	}
+	return &tok, nil
+}
+
+// saveCachedToken writes tok to the cache file for key via a temp-file
+// write plus rename, which POSIX and Windows both guarantee is atomic
+// within a single directory. That's the whole of this package's
+// concurrency guarantee: concurrent helper invocations never observe a
+// partially-written or corrupt cache file, and a loadCachedToken racing a
+// saveCachedToken always gets either the old or the new contents in full.
+// There is no separate file lock, so two helpers refreshing the same key
+// at once (e.g. two concurrent `docker pull`s with a just-expired token)
+// can each mint a token and the later rename wins; this is considered an
+// acceptable, self-correcting race rather than a bug, since the loser's
+// mint was no less valid and the next read picks up whichever write landed
+// last.
+func saveCachedToken(key string, tok *cachedToken) error {
+	dir, err := tokenCacheDir()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(tok)
+	if err != nil {
+		return err
+	}
+
+	dest := filepath.Join(dir, key+".json")
+	tmp, err := os.CreateTemp(dir, key+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), dest)
+}
+
+// clearTokenCache deletes every cached token on disk.
+func clearTokenCache() error {
+	dir, err := tokenCacheDir()
+	if err != nil {
+		return err
+	}
+	err = os.RemoveAll(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// storeDirFn is the function used to locate the store directory; swapped
+// out in tests.
+var storeDirFn = store.DefaultDir
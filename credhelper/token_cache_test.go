@@ -0,0 +1,104 @@
+// Copyright 2016 Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package credhelper
+
+import (
+	"testing"
+	"time"
+)
+
+func withTempStoreDir(t *testing.T) {
+	t.Helper()
+	dir := t.TempDir()
+	old := storeDirFn
+	storeDirFn = func() (string, error) { return dir, nil }
+	t.Cleanup(func() { storeDirFn = old })
+}
+
+func TestTokenCacheRoundTrip(t *testing.T) {
+	withTempStoreDir(t)
+
+	key := tokenCacheKey([]string{"store", "env"}, "", "", nil, []string{"scope-a"})
+	want := &cachedToken{AccessToken: "tok-123", TokenType: "Bearer", Expiry: time.Now().Add(time.Hour)}
+
+	if err := saveCachedToken(key, want); err != nil {
+		t.Fatalf("saveCachedToken() = %v, want nil", err)
+	}
+
+	got, err := loadCachedToken(key)
+	if err != nil {
+		t.Fatalf("loadCachedToken() = %v, want nil", err)
+	}
+	if !got.valid() {
+		t.Fatal("loadCachedToken() returned a token that reports invalid")
+	}
+	if got.AccessToken != want.AccessToken {
+		t.Errorf("AccessToken = %q, want %q", got.AccessToken, want.AccessToken)
+	}
+}
+
+func TestCachedTokenValidRespectsLeeway(t *testing.T) {
+	expiringSoon := &cachedToken{AccessToken: "tok", Expiry: time.Now().Add(10 * time.Second)}
+	if expiringSoon.valid() {
+		t.Error("token expiring within the leeway window should be invalid")
+	}
+
+	fresh := &cachedToken{AccessToken: "tok", Expiry: time.Now().Add(time.Hour)}
+	if !fresh.valid() {
+		t.Error("token expiring in an hour should be valid")
+	}
+}
+
+func TestTokenCacheKeyDiffersByImpersonateTarget(t *testing.T) {
+	key1 := tokenCacheKey([]string{"impersonate"}, "", "sa1@example.com", nil, []string{"scope-a"})
+	key2 := tokenCacheKey([]string{"impersonate"}, "", "sa2@example.com", nil, []string{"scope-a"})
+	if key1 == key2 {
+		t.Error("tokenCacheKey() should differ when the impersonation target differs")
+	}
+}
+
+func TestTokenSourceIsCacheable(t *testing.T) {
+	cases := map[string]bool{
+		"store":              true,
+		"gcloud_sdk":         true,
+		"gcloud_sdk_account": true,
+		"env":                true,
+		"impersonate":        true,
+		"env_token":          false,
+		"token_file":         false,
+	}
+	for source, want := range cases {
+		if got := tokenSourceIsCacheable(source); got != want {
+			t.Errorf("tokenSourceIsCacheable(%q) = %v, want %v", source, got, want)
+		}
+	}
+}
+
+func TestClearTokenCache(t *testing.T) {
+	withTempStoreDir(t)
+
+	key := tokenCacheKey([]string{"store"}, "", "", nil, nil)
+	if err := saveCachedToken(key, &cachedToken{AccessToken: "tok", Expiry: time.Now().Add(time.Hour)}); err != nil {
+		t.Fatalf("saveCachedToken() = %v, want nil", err)
+	}
+
+	if err := clearTokenCache(); err != nil {
+		t.Fatalf("clearTokenCache() = %v, want nil", err)
+	}
+
+	if _, err := loadCachedToken(key); err == nil {
+		t.Error("loadCachedToken() after clearTokenCache() = nil error, want not found")
+	}
+}
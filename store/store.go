@@ -0,0 +1,88 @@
+// Copyright 2016 Google, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package store implements on-disk persistence for GCR and third-party
+registry credentials.
+*/
+package store
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+
+	"github.com/docker/docker-credential-helpers/credentials"
+	"golang.org/x/oauth2"
+)
+
+// DefaultDir returns the directory the on-disk credential store (and
+// anything that wants to live alongside it, like the token cache) is
+// rooted at: "~/.docker-credential-gcr/store".
+func DefaultDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".docker-credential-gcr", "store"), nil
+}
+
+// Auth bundles the credentials needed to mint GCR access tokens. Most
+// users authenticate with a user refresh token (Config/Token); users
+// federating through Workload Identity instead populate
+// ExternalAccountJSON with the verbatim contents of an external_account
+// credential file, which can't be reduced to an oauth2.Config/Token pair.
+type Auth struct {
+	Config oauth2.Config
+	Token  oauth2.Token
+
+	// ExternalAccountJSON holds a raw external_account credential file
+	// (as minted by `gcloud iam workload-identity-pools create-cred-config`
+	// or a CI provider's OIDC integration), when IsExternalAccount is set.
+	ExternalAccountJSON []byte
+}
+
+// IsExternalAccount reports whether a holds a Workload Identity Federation
+// external_account credential rather than a user refresh token.
+func (a *Auth) IsExternalAccount() bool {
+	return len(a.ExternalAccountJSON) > 0
+}
+
+// TokenSource returns an oauth2.TokenSource which refreshes a.Token using
+// a.Config, caching the result the way oauth2.Config.TokenSource does.
+// It is only meaningful when !a.IsExternalAccount(); external_account
+// credentials are exchanged via google.CredentialsFromJSON instead.
+func (a *Auth) TokenSource(ctx context.Context) oauth2.TokenSource {
+	return a.Config.TokenSource(ctx, &a.Token)
+}
+
+// GCRCredStore persists GCR and third-party registry credentials to disk.
+type GCRCredStore interface {
+	// GetGCRAuth returns the stored GCR OAuth2 configuration, if any.
+	GetGCRAuth() (*Auth, error)
+	// SetGCRAuth persists a GCR OAuth2 configuration and initial token.
+	SetGCRAuth(auth *Auth) error
+
+	// GetOtherCreds returns the third-party credentials stored for
+	// serverURL.
+	GetOtherCreds(serverURL string) (*credentials.Credentials, error)
+	// SetOtherCreds persists third-party credentials.
+	SetOtherCreds(creds *credentials.Credentials) error
+	// DeleteOtherCreds removes the third-party credentials stored for
+	// serverURL.
+	DeleteOtherCreds(serverURL string) error
+	// AllThirdPartyCreds returns every stored third-party credential,
+	// keyed by server URL.
+	AllThirdPartyCreds() (map[string]*credentials.Credentials, error)
+}